@@ -0,0 +1,82 @@
+package stores
+
+import (
+	"math/rand"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+)
+
+// AllocRequest describes what AcquireSector is trying to allocate, so an
+// AllocPolicy can filter and score candidate paths.
+type AllocRequest struct {
+	Sector     abi.SectorID
+	SectorSize abi.SectorSize
+	FileType   SectorFileType
+	PathType   PathType
+
+	// ExistingCopies are the storage IDs that already hold some other file
+	// type for Sector, letting policies prefer colocating related files.
+	ExistingCopies []ID
+}
+
+// AllocPolicy picks where AcquireSector should allocate a new sector file
+// among the paths returned by SectorIndex.StorageBestAlloc. Filter excludes
+// candidates outright (in addition to the CanSeal/CanStore/free-space/
+// Weight==0 checks AcquireSector always applies); Score ranks the
+// survivors, and the highest-scoring candidate wins.
+type AllocPolicy interface {
+	Filter(candidate StorageInfo, req AllocRequest) bool
+	Score(candidate StorageInfo, stat FsStat, req AllocRequest) float64
+}
+
+// WeightedRandom scores candidates randomly, weighted by the path's
+// configured Weight. This is close to the historical "whichever path
+// happens to be iterated last" behavior, but actually honors Weight.
+type WeightedRandom struct{}
+
+func (WeightedRandom) Filter(StorageInfo, AllocRequest) bool { return true }
+
+func (WeightedRandom) Score(candidate StorageInfo, _ FsStat, _ AllocRequest) float64 {
+	return float64(candidate.Weight) * rand.Float64()
+}
+
+// MostFree always prefers the candidate with the most available space,
+// spreading sectors evenly across paths.
+type MostFree struct{}
+
+func (MostFree) Filter(StorageInfo, AllocRequest) bool { return true }
+
+func (MostFree) Score(_ StorageInfo, stat FsStat, _ AllocRequest) float64 {
+	return float64(stat.Available)
+}
+
+// Colocated prefers allocating a sector's file on a path that already holds
+// another file type for the same sector (e.g. putting unsealed next to an
+// existing cache), weighing that preference against operator-configured
+// Weight and free space, the same as WeightedRandom's and MostFree's base
+// score. This cuts down on cross-path moves later in the sealing pipeline
+// without letting colocation run roughshod over an operator who weighted
+// paths, or over a path that's nearly full.
+type Colocated struct{}
+
+func (Colocated) Filter(StorageInfo, AllocRequest) bool { return true }
+
+func (Colocated) Score(candidate StorageInfo, stat FsStat, req AllocRequest) float64 {
+	score := float64(candidate.Weight) * float64(stat.Available)
+
+	for _, id := range req.ExistingCopies {
+		if id == candidate.ID {
+			// Colocation saves a later cross-path move, so it's worth a
+			// substantial boost -- but scaled off this same candidate's own
+			// weight*available score, not a fixed constant, so it can't be
+			// drowned out by a large disk nor swamp a comparably-sized one
+			// by an arbitrary, unrelated amount.
+			score *= 4
+			break
+		}
+	}
+
+	return score
+}
+
+var DefaultAllocPolicy AllocPolicy = Colocated{}
@@ -0,0 +1,51 @@
+package stores
+
+import "testing"
+
+func TestWeightedRandomScoreZeroForZeroWeight(t *testing.T) {
+	score := WeightedRandom{}.Score(StorageInfo{Weight: 0}, FsStat{Available: 1 << 30}, AllocRequest{})
+	if score != 0 {
+		t.Fatalf("expected zero score for Weight==0, got %f", score)
+	}
+}
+
+func TestMostFreeScoreIsAvailableSpace(t *testing.T) {
+	score := MostFree{}.Score(StorageInfo{Weight: 1}, FsStat{Available: 12345}, AllocRequest{})
+	if score != 12345 {
+		t.Fatalf("expected score to equal Available, got %f", score)
+	}
+}
+
+func TestColocatedScoreBoostsExistingCopyPath(t *testing.T) {
+	candidate := StorageInfo{ID: "p1", Weight: 2}
+	stat := FsStat{Available: 100}
+
+	base := Colocated{}.Score(candidate, stat, AllocRequest{})
+	if base != 200 {
+		t.Fatalf("expected base score weight*available == 200, got %f", base)
+	}
+
+	boosted := Colocated{}.Score(candidate, stat, AllocRequest{ExistingCopies: []ID{"p1"}})
+	if boosted != base*4 {
+		t.Fatalf("expected colocated score to be 4x the base score (%f), got %f", base*4, boosted)
+	}
+
+	unrelated := Colocated{}.Score(candidate, stat, AllocRequest{ExistingCopies: []ID{"other"}})
+	if unrelated != base {
+		t.Fatalf("expected no boost for an unrelated existing copy, got %f", unrelated)
+	}
+}
+
+// Weight==0 exclusion now lives in AcquireSector itself, common to every
+// policy, in its allocation loop. Filter stays permissive here.
+func TestBuiltinPolicyFiltersAlwaysPass(t *testing.T) {
+	for name, policy := range map[string]AllocPolicy{
+		"WeightedRandom": WeightedRandom{},
+		"MostFree":       MostFree{},
+		"Colocated":      Colocated{},
+	} {
+		if !policy.Filter(StorageInfo{Weight: 0}, AllocRequest{}) {
+			t.Fatalf("%s.Filter rejected a candidate; Weight==0 exclusion belongs to AcquireSector, not the policy", name)
+		}
+	}
+}
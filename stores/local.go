@@ -9,6 +9,7 @@ import (
 	"os"
 	"path/filepath"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"golang.org/x/xerrors"
@@ -38,6 +39,28 @@ type LocalStorageMeta struct {
 // .lotusstorage/storage.json
 type StorageConfig struct {
 	StoragePaths []LocalPath
+
+	// AllocPolicy selects the built-in AllocPolicy Local allocates new
+	// sector files with: "", "colocated" (default), "mostfree", or
+	// "weighted".
+	AllocPolicy string
+}
+
+// AllocPolicyByName resolves the built-in AllocPolicy named by the
+// AllocPolicy field of StorageConfig, defaulting to DefaultAllocPolicy for
+// an empty or unrecognized name.
+func AllocPolicyByName(name string) AllocPolicy {
+	switch name {
+	case "mostfree":
+		return MostFree{}
+	case "weighted":
+		return WeightedRandom{}
+	case "colocated", "":
+		return Colocated{}
+	default:
+		log.Warnf("unknown alloc policy %q, using default", name)
+		return DefaultAllocPolicy
+	}
 }
 
 type LocalPath struct {
@@ -49,6 +72,10 @@ type LocalStorage interface {
 	SetStorage(func(*StorageConfig)) error
 
 	Stat(path string) (FsStat, error)
+
+	// DiskUsage returns the number of bytes actually used on disk by the
+	// file or directory at path.
+	DiskUsage(path string) (int64, error)
 }
 
 const MetaFile = "sectorstore.json"
@@ -59,6 +86,12 @@ type Local struct {
 	localStorage LocalStorage
 	index        SectorIndex
 	urls         []string
+	allocPolicy  AllocPolicy
+
+	// keepUnsealed is the minimum number of durable primary copies (sealed
+	// and cache) that must be declared in the index, on any path, before
+	// RemoveUnsealedIfCopyExists will shed a local unsealed copy.
+	keepUnsealed int
 
 	paths map[ID]*path
 
@@ -67,19 +100,149 @@ type Local struct {
 
 type path struct {
 	local string // absolute local path
+
+	reserved      int64
+	reservationMu sync.Mutex
+	reservations  map[abi.SectorID]SectorFileType
+}
+
+func (p *path) sectorPath(sid abi.SectorID, fileType SectorFileType) string {
+	return filepath.Join(p.local, fileType.String(), SectorName(sid))
+}
+
+// stat returns the path's FsStat with Available adjusted for sectors that
+// are still reserved (acquired via AcquireSector and not yet released by
+// its done func). Once a reserved file actually
+// exists on disk, its on-disk usage is subtracted from the outstanding
+// reservation so we don't double-count it once AcquireSector's caller has
+// started writing.
+func (p *path) stat(ls LocalStorage) (FsStat, error) {
+	stat, err := ls.Stat(p.local)
+	if err != nil {
+		return FsStat{}, xerrors.Errorf("stat %s: %w", p.local, err)
+	}
+
+	reserved := atomic.LoadInt64(&p.reserved)
+
+	p.reservationMu.Lock()
+	for sid := range p.reservations {
+		for _, fileType := range PathTypes {
+			if fileType&p.reservations[sid] == 0 {
+				continue
+			}
+
+			used, err := ls.DiskUsage(p.sectorPath(sid, fileType))
+			if err != nil {
+				if os.IsNotExist(err) {
+					continue
+				}
+				p.reservationMu.Unlock()
+				return FsStat{}, xerrors.Errorf("getting disk usage of reserved sector %s(%d): %w", sid, fileType, err)
+			}
+
+			reserved -= used
+		}
+	}
+	p.reservationMu.Unlock()
+
+	if reserved < 0 {
+		reserved = 0
+	}
+
+	stat.Available -= reserved
+	if stat.Available < 0 {
+		stat.Available = 0
+	}
+
+	return stat, nil
+}
+
+func (p *path) reserve(sid abi.SectorID, fileType SectorFileType, size int64) {
+	p.reservationMu.Lock()
+	if p.reservations == nil {
+		p.reservations = map[abi.SectorID]SectorFileType{}
+	}
+	p.reservations[sid] |= fileType
+	p.reservationMu.Unlock()
+
+	atomic.AddInt64(&p.reserved, size)
+}
+
+func (p *path) release(sid abi.SectorID, fileType SectorFileType, size int64) {
+	p.reservationMu.Lock()
+	p.reservations[sid] &^= fileType
+	if p.reservations[sid] == 0 {
+		delete(p.reservations, sid)
+	}
+	p.reservationMu.Unlock()
+
+	atomic.AddInt64(&p.reserved, -size)
+}
+
+// reservationSize estimates the number of bytes AcquireSector must reserve
+// on a path before allocating fileType for a sector of the given size.
+// Unsealed and sealed files are (at most) one sector in size; the cache
+// directory shrinks to a small fraction of the sector size once sealing
+// completes and TreeD/TreeC/TreeR-Last are discarded, but while PreCommit2
+// is running it holds all of those layers at once, each on the order of
+// the sector size itself -- so we reserve a full sector size for cache
+// too, rather than a fraction of it.
+func reservationSize(fileType SectorFileType, ssize abi.SectorSize) int64 {
+	overhead := int64(ssize)
+
+	switch fileType {
+	case FTUnsealed, FTSealed, FTCache:
+		return overhead
+	default:
+		return 0
+	}
 }
 
 func NewLocal(ctx context.Context, ls LocalStorage, index SectorIndex, urls []string) (*Local, error) {
+	cfg, err := ls.GetStorage()
+	if err != nil {
+		return nil, xerrors.Errorf("getting local storage config: %w", err)
+	}
+
+	return NewLocalWithPolicy(ctx, ls, index, urls, AllocPolicyByName(cfg.AllocPolicy))
+}
+
+// NewLocalWithPolicy is like NewLocal, but lets the caller override the
+// AllocPolicy used to pick a destination path in AcquireSector's allocate
+// path -- see StorageConfig.AllocPolicy for the built-ins selectable from
+// the on-disk config.
+func NewLocalWithPolicy(ctx context.Context, ls LocalStorage, index SectorIndex, urls []string, policy AllocPolicy) (*Local, error) {
 	l := &Local{
 		localStorage: ls,
 		index:        index,
 		urls:         urls,
+		allocPolicy:  policy,
+		keepUnsealed: 1,
 
 		paths: map[ID]*path{},
 	}
 	return l, l.open(ctx)
 }
 
+// SetKeepUnsealed configures how many durable primary copies of a sector
+// RemoveUnsealedIfCopyExists requires to be declared in the index (on any
+// path, this node's included) before it will remove a local unsealed copy.
+func (st *Local) SetKeepUnsealed(keep int) {
+	st.localLk.Lock()
+	defer st.localLk.Unlock()
+
+	st.keepUnsealed = keep
+}
+
+// KeepUnsealed returns the currently configured keepUnsealed threshold; see
+// SetKeepUnsealed.
+func (st *Local) KeepUnsealed() int {
+	st.localLk.RLock()
+	defer st.localLk.RUnlock()
+
+	return st.keepUnsealed
+}
+
 func (st *Local) OpenPath(ctx context.Context, p string) error {
 	st.localLk.Lock()
 	defer st.localLk.Unlock()
@@ -132,7 +295,12 @@ func (st *Local) OpenPath(ctx context.Context, p string) error {
 		for _, ent := range ents {
 			sid, err := ParseSectorID(ent.Name())
 			if err != nil {
-				return xerrors.Errorf("parse sector id %s: %w", ent.Name(), err)
+				// Not a sector file -- e.g. something an operator dropped in
+				// by hand. Declaring
+				// scans have no business erroring out (and refusing to open
+				// the whole path) over an entry they don't recognize.
+				log.Warnf("skipping non-sector entry %s in %s: %+v", ent.Name(), filepath.Join(p, t.String()), err)
+				continue
 			}
 
 			if err := st.index.StorageDeclareSector(ctx, meta.ID, sid, t, meta.CanStore); err != nil {
@@ -143,6 +311,119 @@ func (st *Local) OpenPath(ctx context.Context, p string) error {
 
 	st.paths[meta.ID] = out
 
+	if err := st.resumeMoveJournalsForPath(ctx, meta.ID, out); err != nil {
+		return xerrors.Errorf("resuming move journal for %s: %w", meta.ID, err)
+	}
+
+	return nil
+}
+
+// ClosePath detaches a previously opened storage path: every sector
+// declaration known to the index for id is dropped, and the path stops
+// being considered for new allocations or lookups. This is the inverse of
+// OpenPath, letting an operator safely unmount or remove a disk without
+// restarting.
+func (st *Local) ClosePath(ctx context.Context, id ID) error {
+	st.localLk.Lock()
+	defer st.localLk.Unlock()
+
+	if _, ok := st.paths[id]; !ok {
+		return xerrors.Errorf("path not opened: %s", id)
+	}
+
+	decls, err := st.index.StorageList(ctx)
+	if err != nil {
+		return xerrors.Errorf("listing storage: %w", err)
+	}
+
+	for _, decl := range decls[id] {
+		if err := st.index.StorageDropSector(ctx, id, decl.SectorID, decl.SectorFileType); err != nil {
+			return xerrors.Errorf("dropping sector %d(t:%d) from %s: %w", decl.SectorID, decl.SectorFileType, id, err)
+		}
+	}
+
+	if err := st.index.StorageDetach(ctx, id); err != nil {
+		return xerrors.Errorf("detaching storage %s from index: %w", id, err)
+	}
+
+	delete(st.paths, id)
+
+	return nil
+}
+
+// RedeclarePath rescans a previously opened path's sector directories and
+// reconciles the index with what's actually on disk: sectors present on
+// disk but missing from the index are (re-)declared, and -- if dropMissing
+// is set -- index entries whose backing file has disappeared are dropped.
+// Useful after recovering a path that crashed mid-move, or after manually
+// copying sectors onto a path outside of lotus.
+func (st *Local) RedeclarePath(ctx context.Context, id ID, dropMissing bool) error {
+	st.localLk.RLock()
+	p, ok := st.paths[id]
+	st.localLk.RUnlock()
+	if !ok {
+		return xerrors.Errorf("path not opened: %s", id)
+	}
+
+	mb, err := ioutil.ReadFile(filepath.Join(p.local, MetaFile))
+	if err != nil {
+		return xerrors.Errorf("reading storage metadata for %s: %w", p.local, err)
+	}
+
+	var meta LocalStorageMeta
+	if err := json.Unmarshal(mb, &meta); err != nil {
+		return xerrors.Errorf("unmarshalling storage metadata for %s: %w", p.local, err)
+	}
+
+	onDisk := map[SectorFileType]map[abi.SectorID]struct{}{}
+
+	for _, t := range PathTypes {
+		ents, err := ioutil.ReadDir(filepath.Join(p.local, t.String()))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return xerrors.Errorf("listing %s: %w", filepath.Join(p.local, t.String()), err)
+		}
+
+		found := map[abi.SectorID]struct{}{}
+
+		for _, ent := range ents {
+			sid, err := ParseSectorID(ent.Name())
+			if err != nil {
+				log.Warnf("skipping non-sector entry %s in %s: %+v", ent.Name(), filepath.Join(p.local, t.String()), err)
+				continue
+			}
+
+			found[sid] = struct{}{}
+
+			if err := st.index.StorageDeclareSector(ctx, meta.ID, sid, t, meta.CanStore); err != nil {
+				return xerrors.Errorf("declare sector %d(t:%d) -> %s: %w", sid, t, meta.ID, err)
+			}
+		}
+
+		onDisk[t] = found
+	}
+
+	if !dropMissing {
+		return nil
+	}
+
+	decls, err := st.index.StorageList(ctx)
+	if err != nil {
+		return xerrors.Errorf("listing storage: %w", err)
+	}
+
+	for _, decl := range decls[id] {
+		if _, ok := onDisk[decl.SectorFileType][decl.SectorID]; ok {
+			continue
+		}
+
+		if err := st.index.StorageDropSector(ctx, id, decl.SectorID, decl.SectorFileType); err != nil {
+			return xerrors.Errorf("dropping missing sector %d(t:%d) from %s: %w", decl.SectorID, decl.SectorFileType, id, err)
+		}
+	}
+
 	return nil
 }
 
@@ -159,6 +440,16 @@ func (st *Local) open(ctx context.Context) error {
 		}
 	}
 
+	// OpenPath already resumes/rolls back each path's own move journal as it
+	// attaches, but cfg.StoragePaths is opened in arbitrary order: a
+	// journal's source path can attach after its destination, in which case
+	// resumeMoveJournalsForPath above deferred that entry's source cleanup.
+	// Sweep every attached path once more now that all of them are up, so
+	// startup ordering can't leave one half-resumed.
+	if err := st.resumeMoveJournals(ctx); err != nil {
+		return xerrors.Errorf("resuming move journals: %w", err)
+	}
+
 	go st.reportHealth(ctx)
 
 	return nil
@@ -197,9 +488,20 @@ func (st *Local) reportHealth(ctx context.Context) {
 	}
 }
 
-func (st *Local) AcquireSector(ctx context.Context, sid abi.SectorID, spt abi.RegisteredProof, existing SectorFileType, allocate SectorFileType, pathType PathType, op AcquireMode) (SectorPaths, SectorPaths, error) {
+// AcquireSector locates (or allocates) local paths for the requested sector
+// file types. Sectors are tracked by size rather than by the exact proof
+// type used to seal them, since that's all storage placement cares about --
+// a miner can hold same-size sectors sealed under different proof variants
+// (e.g. across a network upgrade, or snap-deals sectors next to regular
+// ones) and they're interchangeable from the storage layer's perspective.
+//
+// The returned done func must be called once the caller is finished with
+// any newly allocated files; until then the space they are expected to
+// occupy remains reserved against the destination path(s) so that
+// concurrent allocations can't race into the same free space.
+func (st *Local) AcquireSector(ctx context.Context, sid abi.SectorID, ssize abi.SectorSize, existing SectorFileType, allocate SectorFileType, pathType PathType, op AcquireMode) (SectorPaths, SectorPaths, func(), error) {
 	if existing|allocate != existing^allocate {
-		return SectorPaths{}, SectorPaths{}, xerrors.New("can't both find and allocate a sector")
+		return SectorPaths{}, SectorPaths{}, func() {}, xerrors.New("can't both find and allocate a sector")
 	}
 
 	st.localLk.RLock()
@@ -207,19 +509,22 @@ func (st *Local) AcquireSector(ctx context.Context, sid abi.SectorID, spt abi.Re
 
 	var out SectorPaths
 	var storageIDs SectorPaths
+	var existingCopies []ID
 
 	for _, fileType := range PathTypes {
 		if fileType&existing == 0 {
 			continue
 		}
 
-		si, err := st.index.StorageFindSector(ctx, sid, fileType, false)
+		si, err := st.index.StorageFindSector(ctx, sid, fileType, ssize, false)
 		if err != nil {
 			log.Warnf("finding existing sector %d(t:%d) failed: %+v", sid, fileType, err)
 			continue
 		}
 
 		for _, info := range si {
+			existingCopies = append(existingCopies, info.ID)
+
 			p, ok := st.paths[info.ID]
 			if !ok {
 				continue
@@ -238,18 +543,37 @@ func (st *Local) AcquireSector(ctx context.Context, sid abi.SectorID, spt abi.Re
 		}
 	}
 
+	var reservations []func()
+	done := func() {
+		for _, r := range reservations {
+			r()
+		}
+	}
+
 	for _, fileType := range PathTypes {
 		if fileType&allocate == 0 {
 			continue
 		}
 
-		sis, err := st.index.StorageBestAlloc(ctx, fileType, spt, pathType)
+		sis, err := st.index.StorageBestAlloc(ctx, fileType, ssize, pathType)
 		if err != nil {
-			return SectorPaths{}, SectorPaths{}, xerrors.Errorf("finding best storage for allocating : %w", err)
+			done()
+			return SectorPaths{}, SectorPaths{}, func() {}, xerrors.Errorf("finding best storage for allocating : %w", err)
+		}
+
+		req := reservationSize(fileType, ssize)
+		areq := AllocRequest{
+			Sector:         sid,
+			SectorSize:     ssize,
+			FileType:       fileType,
+			PathType:       pathType,
+			ExistingCopies: existingCopies,
 		}
 
 		var best string
 		var bestID ID
+		var bestScore float64
+		haveBest := false
 
 		for _, si := range sis {
 			p, ok := st.paths[si.ID]
@@ -269,22 +593,55 @@ func (st *Local) AcquireSector(ctx context.Context, sid abi.SectorID, spt abi.Re
 				continue
 			}
 
-			// TODO: Check free space
+			if si.Weight == 0 {
+				// 0 = readonly: never a candidate for a new allocation,
+				// regardless of which AllocPolicy is configured.
+				continue
+			}
+
+			if !st.allocPolicy.Filter(si, areq) {
+				continue
+			}
 
+			stat, err := p.stat(st.localStorage)
+			if err != nil {
+				log.Warnf("getting stat for %s: %+v", p.local, err)
+				continue
+			}
+
+			if stat.Available-req < 0 {
+				log.Debugf("not allocating sector %d(t:%d) on %s, out of space (available: %d, required: %d)", sid, fileType, si.ID, stat.Available, req)
+				continue
+			}
+
+			score := st.allocPolicy.Score(si, stat, areq)
+			if haveBest && score <= bestScore {
+				continue
+			}
+
+			haveBest = true
+			bestScore = score
 			best = filepath.Join(p.local, fileType.String(), SectorName(sid))
 			bestID = si.ID
 		}
 
 		if best == "" {
-			return SectorPaths{}, SectorPaths{}, xerrors.Errorf("couldn't find a suitable path for a sector")
+			done()
+			return SectorPaths{}, SectorPaths{}, func() {}, xerrors.Errorf("couldn't find a suitable path for a sector")
 		}
 
+		bp := st.paths[bestID]
+		bp.reserve(sid, fileType, req)
+		reservations = append(reservations, func(fileType SectorFileType, req int64) func() {
+			return func() { bp.release(sid, fileType, req) }
+		}(fileType, req))
+
 		SetPathByType(&out, fileType, best)
 		SetPathByType(&storageIDs, fileType, string(bestID))
 		allocate ^= fileType
 	}
 
-	return out, storageIDs, nil
+	return out, storageIDs, done, nil
 }
 
 func (st *Local) Local(ctx context.Context) ([]StoragePath, error) {
@@ -314,12 +671,18 @@ func (st *Local) Local(ctx context.Context) ([]StoragePath, error) {
 	return out, nil
 }
 
-func (st *Local) Remove(ctx context.Context, sid abi.SectorID, typ SectorFileType, force bool) error {
+// Remove deletes all copies of sector sid(typ) held on this node's attached
+// paths; copies the index lists on other nodes are left alone. If
+// keepPrimaryCopy is set,
+// copies marked Primary in the index are left untouched -- useful when a
+// caller wants to drop redundant/stale copies without risking the last
+// durable copy of a sector.
+func (st *Local) Remove(ctx context.Context, sid abi.SectorID, typ SectorFileType, ssize abi.SectorSize, force bool, keepPrimaryCopy bool) error {
 	if bits.OnesCount(uint(typ)) != 1 {
 		return xerrors.New("delete expects one file type")
 	}
 
-	si, err := st.index.StorageFindSector(ctx, sid, typ, false)
+	si, err := st.index.StorageFindSector(ctx, sid, typ, ssize, false)
 	if err != nil {
 		return xerrors.Errorf("finding existing sector %d(t:%d) failed: %w", sid, typ, err)
 	}
@@ -329,6 +692,10 @@ func (st *Local) Remove(ctx context.Context, sid abi.SectorID, typ SectorFileTyp
 	}
 
 	for _, info := range si {
+		if keepPrimaryCopy && info.Primary {
+			continue
+		}
+
 		if err := st.removeSector(ctx, sid, typ, info.ID); err != nil {
 			return err
 		}
@@ -337,12 +704,49 @@ func (st *Local) Remove(ctx context.Context, sid abi.SectorID, typ SectorFileTyp
 	return nil
 }
 
-func (st *Local) RemoveCopies(ctx context.Context, sid abi.SectorID, typ SectorFileType) error {
+// RemoveUnsealedIfCopyExists removes locally-held unsealed copies of sid
+// once at least keepCount durable primary copies (sealed and cache) are
+// declared in the index; primary copies on this node's own paths count
+// toward keepCount too. This mirrors the "remove sector copies from
+// workers after snap-deals" pattern: after FinalizeSector or a snap-deal,
+// workers can shed unsealed data they no longer need, but only once it's
+// durably replaced. Callers without an opinion on keepCount should pass
+// st.keepUnsealed (see SetKeepUnsealed).
+func (st *Local) RemoveUnsealedIfCopyExists(ctx context.Context, sid abi.SectorID, ssize abi.SectorSize, keepCount int) error {
+	sealed, err := st.index.StorageFindSector(ctx, sid, FTSealed, ssize, false)
+	if err != nil {
+		return xerrors.Errorf("finding sealed copies of %v: %w", sid, err)
+	}
+
+	cache, err := st.index.StorageFindSector(ctx, sid, FTCache, ssize, false)
+	if err != nil {
+		return xerrors.Errorf("finding cache copies of %v: %w", sid, err)
+	}
+
+	if primaryCount(sealed) < keepCount || primaryCount(cache) < keepCount {
+		log.Debugf("not removing unsealed copies of %v: not enough durable primary copies yet", sid)
+		return nil
+	}
+
+	return st.Remove(ctx, sid, FTUnsealed, ssize, false, false)
+}
+
+func primaryCount(si []StorageInfo) int {
+	n := 0
+	for _, info := range si {
+		if info.Primary {
+			n++
+		}
+	}
+	return n
+}
+
+func (st *Local) RemoveCopies(ctx context.Context, sid abi.SectorID, typ SectorFileType, ssize abi.SectorSize) error {
 	if bits.OnesCount(uint(typ)) != 1 {
 		return xerrors.New("delete expects one file type")
 	}
 
-	si, err := st.index.StorageFindSector(ctx, sid, typ, false)
+	si, err := st.index.StorageFindSector(ctx, sid, typ, ssize, false)
 	if err != nil {
 		return xerrors.Errorf("finding existing sector %d(t:%d) failed: %w", sid, typ, err)
 	}
@@ -373,6 +777,83 @@ func (st *Local) RemoveCopies(ctx context.Context, sid abi.SectorID, typ SectorF
 	return nil
 }
 
+// Cleanup garbage-collects orphan sector files: files present on seal-only
+// (non-CanStore) paths with no corresponding entry in the sector index,
+// typically left behind by a crash between finishing a move and removing
+// the source. If keepUnsealed is true, orphaned unsealed files are left in
+// place; sealed and cache files are always collected.
+func (st *Local) Cleanup(ctx context.Context, keepUnsealed bool) error {
+	st.localLk.RLock()
+	paths := make(map[ID]*path, len(st.paths))
+	for id, p := range st.paths {
+		paths[id] = p
+	}
+	st.localLk.RUnlock()
+
+	// StorageList doesn't require already knowing a sector's size, unlike
+	// StorageFindSector -- which we'd otherwise have no correct size to pass
+	// here, since these are arbitrary sector files found by scanning disk.
+	decls, err := st.index.StorageList(ctx)
+	if err != nil {
+		return xerrors.Errorf("listing storage: %w", err)
+	}
+
+	for id, p := range paths {
+		si, err := st.index.StorageInfo(ctx, id)
+		if err != nil {
+			return xerrors.Errorf("get storage info for %s: %w", id, err)
+		}
+
+		if si.CanStore {
+			// files on store paths are expected to stick around
+			continue
+		}
+
+		declared := map[SectorFileType]map[abi.SectorID]struct{}{}
+		for _, d := range decls[id] {
+			if declared[d.SectorFileType] == nil {
+				declared[d.SectorFileType] = map[abi.SectorID]struct{}{}
+			}
+			declared[d.SectorFileType][d.SectorID] = struct{}{}
+		}
+
+		for _, t := range PathTypes {
+			if keepUnsealed && t == FTUnsealed {
+				continue
+			}
+
+			ents, err := ioutil.ReadDir(filepath.Join(p.local, t.String()))
+			if err != nil {
+				if os.IsNotExist(err) {
+					continue
+				}
+				return xerrors.Errorf("listing %s: %w", filepath.Join(p.local, t.String()), err)
+			}
+
+			for _, ent := range ents {
+				sid, err := ParseSectorID(ent.Name())
+				if err != nil {
+					log.Warnf("skipping non-sector entry %s in %s: %+v", ent.Name(), filepath.Join(p.local, t.String()), err)
+					continue
+				}
+
+				if _, owned := declared[t][sid]; owned {
+					continue
+				}
+
+				spath := filepath.Join(p.local, t.String(), ent.Name())
+				log.Warnf("cleanup: removing orphan sector file %s", spath)
+
+				if err := os.RemoveAll(spath); err != nil {
+					log.Errorf("removing orphan sector file %s: %+v", spath, err)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
 func (st *Local) removeSector(ctx context.Context, sid abi.SectorID, typ SectorFileType, storage ID) error {
 	p, ok := st.paths[storage]
 	if !ok {
@@ -397,16 +878,18 @@ func (st *Local) removeSector(ctx context.Context, sid abi.SectorID, typ SectorF
 	return nil
 }
 
-func (st *Local) MoveStorage(ctx context.Context, s abi.SectorID, spt abi.RegisteredProof, types SectorFileType) error {
-	dest, destIds, err := st.AcquireSector(ctx, s, spt, FTNone, types, false, AcquireMove)
+func (st *Local) MoveStorage(ctx context.Context, s abi.SectorID, ssize abi.SectorSize, types SectorFileType) error {
+	dest, destIds, destDone, err := st.AcquireSector(ctx, s, ssize, FTNone, types, false, AcquireMove)
 	if err != nil {
 		return xerrors.Errorf("acquire dest storage: %w", err)
 	}
+	defer destDone()
 
-	src, srcIds, err := st.AcquireSector(ctx, s, spt, types, FTNone, false, AcquireMove)
+	src, srcIds, srcDone, err := st.AcquireSector(ctx, s, ssize, types, FTNone, false, AcquireMove)
 	if err != nil {
 		return xerrors.Errorf("acquire src storage: %w", err)
 	}
+	defer srcDone()
 
 	for _, fileType := range PathTypes {
 		if fileType&types == 0 {
@@ -435,17 +918,51 @@ func (st *Local) MoveStorage(ctx context.Context, s abi.SectorID, spt abi.Regist
 
 		log.Debugf("moving %v(%d) to storage: %s(se:%t; st:%t) -> %s(se:%t; st:%t)", s, fileType, sst.ID, sst.CanSeal, sst.CanStore, dst.ID, dst.CanSeal, dst.CanStore)
 
-		if err := st.index.StorageDropSector(ctx, ID(PathByType(srcIds, fileType)), s, fileType); err != nil {
-			return xerrors.Errorf("dropping source sector from index: %w", err)
+		srcPath := PathByType(src, fileType)
+		dstPath := PathByType(dest, fileType)
+
+		dstLocal, ok := st.paths[dst.ID]
+		if !ok {
+			return xerrors.Errorf("destination path %s not attached", dst.ID)
+		}
+
+		size, err := st.localStorage.DiskUsage(srcPath)
+		if err != nil {
+			return xerrors.Errorf("getting size of %s: %w", srcPath, err)
 		}
 
-		if err := move(PathByType(src, fileType), PathByType(dest, fileType)); err != nil {
-			// TODO: attempt some recovery (check if src is still there, re-declare)
+		// Record the move before touching anything, so that a crash between
+		// here and the index update leaves enough breadcrumbs for the next
+		// OpenPath of the destination to resume or roll back the copy -- see
+		// resumeMoveJournalsForPath.
+		if err := writeMoveJournal(dstLocal.local, moveJournalEntry{
+			Source:   sst.ID,
+			Dest:     dst.ID,
+			Sector:   s,
+			FileType: fileType,
+			Size:     size,
+		}); err != nil {
+			return xerrors.Errorf("writing move journal: %w", err)
+		}
+
+		if err := moveFile(dstLocal.local, s, fileType, srcPath, dstPath); err != nil {
 			return xerrors.Errorf("moving sector %v(%d): %w", s, fileType, err)
 		}
 
-		if err := st.index.StorageDeclareSector(ctx, ID(PathByType(destIds, fileType)), s, fileType, true); err != nil {
-			return xerrors.Errorf("declare sector %d(t:%d) -> %s: %w", s, fileType, ID(PathByType(destIds, fileType)), err)
+		if err := st.index.StorageDeclareSector(ctx, dst.ID, s, fileType, true); err != nil {
+			return xerrors.Errorf("declare sector %d(t:%d) -> %s: %w", s, fileType, dst.ID, err)
+		}
+
+		if err := st.index.StorageDropSector(ctx, sst.ID, s, fileType); err != nil {
+			return xerrors.Errorf("dropping source sector from index: %w", err)
+		}
+
+		if err := os.RemoveAll(srcPath); err != nil {
+			log.Errorf("removing source %s after move: %+v", srcPath, err)
+		}
+
+		if err := removeMoveJournal(dstLocal.local, s, fileType); err != nil {
+			log.Errorf("removing move journal entry for %v(t:%d): %+v", s, fileType, err)
 		}
 	}
 
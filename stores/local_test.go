@@ -0,0 +1,95 @@
+package stores
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+)
+
+// fakeCleanupIndex backs Local.Cleanup with a fixed set of declared sectors
+// and per-path StorageInfo. It embeds SectorIndex (left nil) so it satisfies
+// the full interface without stubbing out every method; only the two
+// methods Cleanup actually calls are overridden here.
+type fakeCleanupIndex struct {
+	SectorIndex
+
+	decls map[ID][]Decl
+	info  map[ID]StorageInfo
+}
+
+func (f *fakeCleanupIndex) StorageList(context.Context) (map[ID][]Decl, error) {
+	return f.decls, nil
+}
+
+func (f *fakeCleanupIndex) StorageInfo(_ context.Context, id ID) (StorageInfo, error) {
+	return f.info[id], nil
+}
+
+func TestCleanupRemovesOrphansButKeepsDeclared(t *testing.T) {
+	dir := t.TempDir()
+	p := &path{local: dir}
+
+	declared := abi.SectorID{Miner: 1, Number: 1}
+	orphan := abi.SectorID{Miner: 1, Number: 2}
+
+	if err := os.MkdirAll(filepath.Join(dir, FTSealed.String()), 0755); err != nil {
+		t.Fatal(err)
+	}
+	declaredFile := p.sectorPath(declared, FTSealed)
+	orphanFile := p.sectorPath(orphan, FTSealed)
+	if err := ioutil.WriteFile(declaredFile, []byte("d"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(orphanFile, []byte("o"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	idx := &fakeCleanupIndex{
+		decls: map[ID][]Decl{"p1": {{SectorID: declared, SectorFileType: FTSealed}}},
+		info:  map[ID]StorageInfo{"p1": {ID: "p1", CanStore: false}},
+	}
+	st := &Local{index: idx, paths: map[ID]*path{"p1": p}}
+
+	if err := st.Cleanup(context.Background(), false); err != nil {
+		t.Fatalf("Cleanup: %+v", err)
+	}
+
+	if _, err := os.Stat(declaredFile); err != nil {
+		t.Fatalf("expected declared sector file kept, stat err = %v", err)
+	}
+	if _, err := os.Stat(orphanFile); !os.IsNotExist(err) {
+		t.Fatalf("expected orphan sector file removed, stat err = %v", err)
+	}
+}
+
+func TestCleanupSkipsCanStorePaths(t *testing.T) {
+	dir := t.TempDir()
+	p := &path{local: dir}
+
+	orphan := abi.SectorID{Miner: 1, Number: 3}
+	if err := os.MkdirAll(filepath.Join(dir, FTSealed.String()), 0755); err != nil {
+		t.Fatal(err)
+	}
+	orphanFile := p.sectorPath(orphan, FTSealed)
+	if err := ioutil.WriteFile(orphanFile, []byte("o"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	idx := &fakeCleanupIndex{
+		decls: map[ID][]Decl{},
+		info:  map[ID]StorageInfo{"p1": {ID: "p1", CanStore: true}},
+	}
+	st := &Local{index: idx, paths: map[ID]*path{"p1": p}}
+
+	if err := st.Cleanup(context.Background(), false); err != nil {
+		t.Fatalf("Cleanup: %+v", err)
+	}
+
+	if _, err := os.Stat(orphanFile); err != nil {
+		t.Fatalf("expected file on a store path left untouched, stat err = %v", err)
+	}
+}
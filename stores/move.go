@@ -0,0 +1,302 @@
+package stores
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+)
+
+const moveJournalDir = ".move-journal"
+
+// moveJournalEntry records an in-flight cross-path sector file move so it
+// can be resumed or rolled back if the process is killed mid-move. It's
+// written under the *destination* path before the copy starts, and removed
+// only after the copy is fsynced and the sector index points at the new
+// location.
+type moveJournalEntry struct {
+	Source   ID
+	Dest     ID
+	Sector   abi.SectorID
+	FileType SectorFileType
+	Size     int64
+}
+
+func moveJournalPath(destLocal string, sector abi.SectorID, ft SectorFileType) string {
+	return filepath.Join(destLocal, moveJournalDir, fmt.Sprintf("%s-%d.json", SectorName(sector), ft))
+}
+
+// moveTmpDir is where in-flight copies are staged. It's a sibling of
+// unsealed/sealed/cache under moveJournalDir, not inside any of them, so
+// that OpenPath/RedeclarePath/Cleanup's PathTypes scans -- which feed
+// directory entries straight into ParseSectorID -- never see a partially
+// written file.
+func moveTmpDir(destLocal string) string {
+	return filepath.Join(destLocal, moveJournalDir, "tmp")
+}
+
+func moveTmpPath(destLocal string, sector abi.SectorID, ft SectorFileType) string {
+	return filepath.Join(moveTmpDir(destLocal), fmt.Sprintf("%s-%d.tmp", SectorName(sector), ft))
+}
+
+func writeMoveJournal(destLocal string, e moveJournalEntry) error {
+	dir := filepath.Join(destLocal, moveJournalDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return xerrors.Errorf("creating move journal dir %s: %w", dir, err)
+	}
+
+	b, err := json.Marshal(&e)
+	if err != nil {
+		return xerrors.Errorf("marshaling move journal entry: %w", err)
+	}
+
+	p := moveJournalPath(destLocal, e.Sector, e.FileType)
+	if err := ioutil.WriteFile(p, b, 0644); err != nil {
+		return xerrors.Errorf("writing move journal entry %s: %w", p, err)
+	}
+
+	return fsyncDir(dir)
+}
+
+func removeMoveJournal(destLocal string, sector abi.SectorID, ft SectorFileType) error {
+	p := moveJournalPath(destLocal, sector, ft)
+	if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+		return xerrors.Errorf("removing move journal entry %s: %w", p, err)
+	}
+
+	return fsyncDir(filepath.Dir(p))
+}
+
+func fsyncDir(dir string) error {
+	f, err := os.Open(dir)
+	if err != nil {
+		return xerrors.Errorf("opening %s for fsync: %w", dir, err)
+	}
+	defer f.Close()
+
+	if err := f.Sync(); err != nil {
+		return xerrors.Errorf("fsyncing %s: %w", dir, err)
+	}
+
+	return nil
+}
+
+// moveFile performs a crash-safe move of a single sector file or directory:
+// the source tree is copied into moveTmpDir (outside unsealed/sealed/cache,
+// so it's invisible to the declare-scan loops), fsynced, atomically renamed
+// into place, and the destination's parent directory is fsynced. The source
+// is left untouched -- the caller removes it only once the sector index
+// points at the new location.
+func moveFile(destLocal string, sector abi.SectorID, fileType SectorFileType, from, to string) error {
+	if from == to {
+		return nil
+	}
+
+	if err := os.MkdirAll(moveTmpDir(destLocal), 0755); err != nil {
+		return xerrors.Errorf("creating move tmp dir: %w", err)
+	}
+
+	tmp := moveTmpPath(destLocal, sector, fileType)
+	if err := os.RemoveAll(tmp); err != nil {
+		return xerrors.Errorf("removing stale temp path %s: %w", tmp, err)
+	}
+
+	if err := copyTree(from, tmp); err != nil {
+		_ = os.RemoveAll(tmp)
+		return xerrors.Errorf("copying %s to %s: %w", from, tmp, err)
+	}
+
+	if err := os.RemoveAll(to); err != nil {
+		return xerrors.Errorf("removing existing %s: %w", to, err)
+	}
+
+	if err := os.Rename(tmp, to); err != nil {
+		return xerrors.Errorf("renaming %s to %s: %w", tmp, to, err)
+	}
+
+	return fsyncDir(filepath.Dir(to))
+}
+
+func copyTree(from, to string) error {
+	fi, err := os.Stat(from)
+	if err != nil {
+		return err
+	}
+
+	if !fi.IsDir() {
+		return copyFileSync(from, to)
+	}
+
+	if err := os.MkdirAll(to, 0755); err != nil {
+		return err
+	}
+
+	ents, err := ioutil.ReadDir(from)
+	if err != nil {
+		return err
+	}
+
+	for _, ent := range ents {
+		if err := copyTree(filepath.Join(from, ent.Name()), filepath.Join(to, ent.Name())); err != nil {
+			return err
+		}
+	}
+
+	return fsyncDir(to)
+}
+
+func copyFileSync(from, to string) error {
+	in, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(to, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+
+	return out.Close()
+}
+
+// resumeMoveJournalsForPath scans a single attached path for journal entries
+// left behind by a move that was interrupted by a crash or restart, and
+// either resumes (destination fully copied) or rolls back (destination
+// incomplete) each one addressed to it. OpenPath calls this directly so
+// that a path attached after startup -- e.g. an operator reattaching a
+// drive via ClosePath/OpenPath without restarting -- gets the same
+// resume/rollback treatment as paths opened at process start.
+func (st *Local) resumeMoveJournalsForPath(ctx context.Context, id ID, p *path) error {
+	dir := filepath.Join(p.local, moveJournalDir)
+
+	ents, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return xerrors.Errorf("listing move journal in %s: %w", dir, err)
+	}
+
+	for _, ent := range ents {
+		if ent.IsDir() {
+			// moveTmpDir lives under moveJournalDir so in-flight copies
+			// stay out of the unsealed/sealed/cache scans; skip it here too.
+			continue
+		}
+
+		jp := filepath.Join(dir, ent.Name())
+
+		b, err := ioutil.ReadFile(jp)
+		if err != nil {
+			log.Errorf("reading move journal entry %s: %+v", jp, err)
+			continue
+		}
+
+		var e moveJournalEntry
+		if err := json.Unmarshal(b, &e); err != nil {
+			log.Errorf("unmarshaling move journal entry %s: %+v", jp, err)
+			continue
+		}
+
+		if e.Dest != id {
+			// belongs to a path we haven't attached (yet); leave it
+			continue
+		}
+
+		if err := st.resumeMoveJournalEntry(ctx, p, e); err != nil {
+			log.Errorf("resuming move of sector %v(t:%d): %+v", e.Sector, e.FileType, err)
+		}
+	}
+
+	return nil
+}
+
+// resumeMoveJournalEntry resumes or rolls back a single journal entry. It is
+// safe to call more than once for the same entry: the index updates are
+// idempotent. For a completed move the journal entry is kept until the
+// source path is attached and removal of the source copy has been attempted;
+// for a rollback it is removed once the incomplete destination and staged
+// temp copy are deleted, leaving the source in place.
+func (st *Local) resumeMoveJournalEntry(ctx context.Context, dst *path, e moveJournalEntry) error {
+	dstPath := dst.sectorPath(e.Sector, e.FileType)
+
+	used, statErr := st.localStorage.DiskUsage(dstPath)
+	complete := statErr == nil && used >= e.Size
+
+	if !complete {
+		log.Warnf("rolling back incomplete move of sector %v(t:%d) into %s after restart", e.Sector, e.FileType, dst.local)
+
+		if err := os.RemoveAll(dstPath); err != nil {
+			return xerrors.Errorf("removing incomplete destination %s: %w", dstPath, err)
+		}
+
+		tmp := moveTmpPath(dst.local, e.Sector, e.FileType)
+		if err := os.RemoveAll(tmp); err != nil {
+			return xerrors.Errorf("removing incomplete temp destination %s: %w", tmp, err)
+		}
+
+		return removeMoveJournal(dst.local, e.Sector, e.FileType)
+	}
+
+	log.Warnf("resuming completed move of sector %v(t:%d) into %s after restart", e.Sector, e.FileType, dst.local)
+
+	if err := st.index.StorageDeclareSector(ctx, e.Dest, e.Sector, e.FileType, true); err != nil {
+		return xerrors.Errorf("declaring resumed sector: %w", err)
+	}
+
+	if err := st.index.StorageDropSector(ctx, e.Source, e.Sector, e.FileType); err != nil {
+		return xerrors.Errorf("dropping source sector: %w", err)
+	}
+
+	srcp, ok := st.paths[e.Source]
+	if !ok {
+		// Source path isn't attached yet (startup ordering: paths are
+		// opened in whatever order the config lists them in). Leave the
+		// journal entry in place -- either that path's own OpenPath, or the
+		// full-tree sweep open() runs once every configured path is
+		// attached, will find this entry again and finish the job.
+		log.Debugf("move journal for sector %v(t:%d): source %s not attached yet, deferring source cleanup", e.Sector, e.FileType, e.Source)
+		return nil
+	}
+
+	if err := os.RemoveAll(srcp.sectorPath(e.Sector, e.FileType)); err != nil {
+		log.Errorf("removing source after resumed move: %+v", err)
+	}
+
+	return removeMoveJournal(dst.local, e.Sector, e.FileType)
+}
+
+// resumeMoveJournals performs a full-tree resume/rollback pass across every
+// currently-attached path. OpenPath already does this for the one path it's
+// attaching, which is sufficient for a live ClosePath/OpenPath reattach; but
+// at startup, cfg.StoragePaths is opened in arbitrary order, so a journal
+// entry's source path may not be attached yet when its destination's
+// OpenPath runs resumeMoveJournalsForPath. open() calls this once after all
+// configured paths are open to pick up anything left deferred by ordering.
+func (st *Local) resumeMoveJournals(ctx context.Context) error {
+	for id, p := range st.paths {
+		if err := st.resumeMoveJournalsForPath(ctx, id, p); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,145 @@
+package stores
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+)
+
+// fakeMoveIndex records the StorageDeclareSector/StorageDropSector calls
+// resumeMoveJournalEntry makes. It embeds SectorIndex (left nil) so it
+// satisfies the full interface without stubbing out every method; only the
+// two methods resumeMoveJournalEntry actually calls are overridden here.
+type fakeMoveIndex struct {
+	SectorIndex
+
+	declared []abi.SectorID
+	dropped  []abi.SectorID
+}
+
+func (f *fakeMoveIndex) StorageDeclareSector(_ context.Context, _ ID, s abi.SectorID, _ SectorFileType, _ bool) error {
+	f.declared = append(f.declared, s)
+	return nil
+}
+
+func (f *fakeMoveIndex) StorageDropSector(_ context.Context, _ ID, s abi.SectorID, _ SectorFileType) error {
+	f.dropped = append(f.dropped, s)
+	return nil
+}
+
+// fakeDiskUsage is a LocalStorage that reports a fixed size for DiskUsage,
+// regardless of path, so tests can simulate a complete or partial copy.
+type fakeDiskUsage struct {
+	size int64
+}
+
+func (f fakeDiskUsage) GetStorage() (StorageConfig, error)    { return StorageConfig{}, nil }
+func (f fakeDiskUsage) SetStorage(func(*StorageConfig)) error { return nil }
+func (f fakeDiskUsage) Stat(string) (FsStat, error)           { return FsStat{}, nil }
+func (f fakeDiskUsage) DiskUsage(string) (int64, error)       { return f.size, nil }
+
+func TestResumeMoveJournalEntryResumesCompleteMove(t *testing.T) {
+	srcDir := t.TempDir()
+	dstDir := t.TempDir()
+
+	sector := abi.SectorID{Miner: 1, Number: 1}
+	ft := FTSealed
+
+	src := &path{local: srcDir}
+	dst := &path{local: dstDir}
+
+	if err := os.MkdirAll(filepath.Join(srcDir, ft.String()), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dstDir, ft.String()), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	srcFile := src.sectorPath(sector, ft)
+	dstFile := dst.sectorPath(sector, ft)
+	data := []byte("sector data")
+	if err := ioutil.WriteFile(srcFile, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(dstFile, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	e := moveJournalEntry{Source: "src", Dest: "dst", Sector: sector, FileType: ft, Size: int64(len(data))}
+	if err := writeMoveJournal(dstDir, e); err != nil {
+		t.Fatal(err)
+	}
+
+	idx := &fakeMoveIndex{}
+	st := &Local{
+		index:        idx,
+		localStorage: fakeDiskUsage{size: int64(len(data))},
+		paths:        map[ID]*path{"src": src, "dst": dst},
+	}
+
+	if err := st.resumeMoveJournalEntry(context.Background(), dst, e); err != nil {
+		t.Fatalf("resumeMoveJournalEntry: %+v", err)
+	}
+
+	if len(idx.declared) != 1 || idx.declared[0] != sector {
+		t.Fatalf("expected sector declared at dest, got %v", idx.declared)
+	}
+	if len(idx.dropped) != 1 || idx.dropped[0] != sector {
+		t.Fatalf("expected sector dropped at source, got %v", idx.dropped)
+	}
+	if _, err := os.Stat(srcFile); !os.IsNotExist(err) {
+		t.Fatalf("expected source file removed, stat err = %v", err)
+	}
+	if _, err := os.Stat(moveJournalPath(dstDir, sector, ft)); !os.IsNotExist(err) {
+		t.Fatalf("expected journal entry removed, stat err = %v", err)
+	}
+}
+
+func TestResumeMoveJournalEntryRollsBackIncompleteMove(t *testing.T) {
+	dstDir := t.TempDir()
+
+	sector := abi.SectorID{Miner: 1, Number: 2}
+	ft := FTSealed
+	dst := &path{local: dstDir}
+
+	if err := os.MkdirAll(filepath.Join(dstDir, ft.String()), 0755); err != nil {
+		t.Fatal(err)
+	}
+	dstFile := dst.sectorPath(sector, ft)
+	if err := ioutil.WriteFile(dstFile, []byte("partial"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	e := moveJournalEntry{Source: "src", Dest: "dst", Sector: sector, FileType: ft, Size: 1 << 20}
+	if err := writeMoveJournal(dstDir, e); err != nil {
+		t.Fatal(err)
+	}
+
+	idx := &fakeMoveIndex{}
+	st := &Local{
+		index:        idx,
+		localStorage: fakeDiskUsage{size: int64(len("partial"))},
+		paths:        map[ID]*path{"dst": dst},
+	}
+
+	if err := st.resumeMoveJournalEntry(context.Background(), dst, e); err != nil {
+		t.Fatalf("resumeMoveJournalEntry: %+v", err)
+	}
+
+	if len(idx.declared) != 0 || len(idx.dropped) != 0 {
+		t.Fatalf("expected no index changes on rollback, got declared=%v dropped=%v", idx.declared, idx.dropped)
+	}
+	if _, err := os.Stat(dstFile); !os.IsNotExist(err) {
+		t.Fatalf("expected incomplete destination removed, stat err = %v", err)
+	}
+	if _, err := os.Stat(moveTmpPath(dstDir, sector, ft)); !os.IsNotExist(err) {
+		t.Fatalf("expected staged temp copy removed, stat err = %v", err)
+	}
+	if _, err := os.Stat(moveJournalPath(dstDir, sector, ft)); !os.IsNotExist(err) {
+		t.Fatalf("expected journal entry removed after rollback, stat err = %v", err)
+	}
+}
@@ -0,0 +1,81 @@
+package stores
+
+import (
+	"os"
+	"testing"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+)
+
+// fakeStatStorage is a LocalStorage backed by fixed, in-memory numbers
+// rather than a real filesystem, so path.stat's reservation arithmetic can
+// be tested without touching disk.
+type fakeStatStorage struct {
+	available int64
+	usage     map[string]int64 // path -> on-disk usage; absent means not yet materialized
+}
+
+func (f fakeStatStorage) GetStorage() (StorageConfig, error)    { return StorageConfig{}, nil }
+func (f fakeStatStorage) SetStorage(func(*StorageConfig)) error { return nil }
+func (f fakeStatStorage) Stat(string) (FsStat, error)           { return FsStat{Available: f.available}, nil }
+
+func (f fakeStatStorage) DiskUsage(p string) (int64, error) {
+	u, ok := f.usage[p]
+	if !ok {
+		return 0, os.ErrNotExist
+	}
+	return u, nil
+}
+
+func TestPathStatSubtractsOutstandingReservations(t *testing.T) {
+	p := &path{local: "/fake"}
+	ls := fakeStatStorage{available: 1000, usage: map[string]int64{}}
+
+	sid := abi.SectorID{Miner: 1, Number: 1}
+	p.reserve(sid, FTSealed, 200)
+
+	stat, err := p.stat(ls)
+	if err != nil {
+		t.Fatalf("stat: %+v", err)
+	}
+	if stat.Available != 800 {
+		t.Fatalf("expected reservation to reduce Available to 800, got %d", stat.Available)
+	}
+}
+
+func TestPathStatDoesNotDoubleCountMaterializedReservation(t *testing.T) {
+	p := &path{local: "/fake"}
+	sid := abi.SectorID{Miner: 1, Number: 2}
+	p.reserve(sid, FTSealed, 200)
+
+	// 120 of the reserved 200 bytes have actually landed on disk; the
+	// remaining 80 bytes of the reservation are still outstanding.
+	ls := fakeStatStorage{available: 1000, usage: map[string]int64{
+		p.sectorPath(sid, FTSealed): 120,
+	}}
+
+	stat, err := p.stat(ls)
+	if err != nil {
+		t.Fatalf("stat: %+v", err)
+	}
+	if stat.Available != 920 {
+		t.Fatalf("expected only the un-materialized 80 bytes to still be reserved (Available 920), got %d", stat.Available)
+	}
+}
+
+func TestPathStatAfterReleaseReflectsRawAvailable(t *testing.T) {
+	p := &path{local: "/fake"}
+	ls := fakeStatStorage{available: 1000, usage: map[string]int64{}}
+
+	sid := abi.SectorID{Miner: 1, Number: 3}
+	p.reserve(sid, FTSealed, 200)
+	p.release(sid, FTSealed, 200)
+
+	stat, err := p.stat(ls)
+	if err != nil {
+		t.Fatalf("stat: %+v", err)
+	}
+	if stat.Available != 1000 {
+		t.Fatalf("expected Available to be unreserved after release, got %d", stat.Available)
+	}
+}
@@ -0,0 +1,74 @@
+package stores
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+)
+
+func TestPrimaryCount(t *testing.T) {
+	si := []StorageInfo{
+		{ID: "p1", Primary: true},
+		{ID: "p2", Primary: false},
+		{ID: "p3", Primary: true},
+	}
+	if n := primaryCount(si); n != 2 {
+		t.Fatalf("expected 2 primary copies, got %d", n)
+	}
+}
+
+// fakeRemoveIndex answers StorageFindSector from canned per-file-type
+// results and records StorageDropSector calls, so RemoveUnsealedIfCopyExists
+// can be tested without a full SectorIndex.
+type fakeRemoveIndex struct {
+	SectorIndex
+
+	byType  map[SectorFileType][]StorageInfo
+	dropped []SectorFileType
+}
+
+func (f *fakeRemoveIndex) StorageFindSector(_ context.Context, _ abi.SectorID, ft SectorFileType, _ abi.SectorSize, _ bool) ([]StorageInfo, error) {
+	return f.byType[ft], nil
+}
+
+func (f *fakeRemoveIndex) StorageDropSector(_ context.Context, _ ID, _ abi.SectorID, ft SectorFileType) error {
+	f.dropped = append(f.dropped, ft)
+	return nil
+}
+
+func TestRemoveUnsealedIfCopyExistsRemovesOnceDurablePrimaryExists(t *testing.T) {
+	sid := abi.SectorID{Miner: 1, Number: 1}
+	idx := &fakeRemoveIndex{byType: map[SectorFileType][]StorageInfo{
+		FTSealed:   {{ID: "p1", Primary: true}},
+		FTCache:    {{ID: "p1", Primary: true}},
+		FTUnsealed: {{ID: "p1", Primary: false}},
+	}}
+	st := &Local{index: idx, paths: map[ID]*path{"p1": {local: t.TempDir()}}}
+
+	if err := st.RemoveUnsealedIfCopyExists(context.Background(), sid, 0, 1); err != nil {
+		t.Fatalf("RemoveUnsealedIfCopyExists: %+v", err)
+	}
+
+	if len(idx.dropped) != 1 || idx.dropped[0] != FTUnsealed {
+		t.Fatalf("expected the unsealed copy to be dropped, got %v", idx.dropped)
+	}
+}
+
+func TestRemoveUnsealedIfCopyExistsKeepsWithoutEnoughPrimaries(t *testing.T) {
+	sid := abi.SectorID{Miner: 1, Number: 2}
+	idx := &fakeRemoveIndex{byType: map[SectorFileType][]StorageInfo{
+		FTSealed:   {{ID: "p1", Primary: false}},
+		FTCache:    {{ID: "p1", Primary: true}},
+		FTUnsealed: {{ID: "p1", Primary: false}},
+	}}
+	st := &Local{index: idx, paths: map[ID]*path{"p1": {local: t.TempDir()}}}
+
+	if err := st.RemoveUnsealedIfCopyExists(context.Background(), sid, 0, 1); err != nil {
+		t.Fatalf("RemoveUnsealedIfCopyExists: %+v", err)
+	}
+
+	if len(idx.dropped) != 0 {
+		t.Fatalf("expected no copies dropped without a durable primary sealed copy, got %v", idx.dropped)
+	}
+}